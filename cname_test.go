@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+)
+
+func TestDelegatedCNAMETarget(t *testing.T) {
+	tests := []struct {
+		name       string
+		fqdn       string
+		suffix     string
+		lookupName string
+		lookupErr  error
+		wantTarget string
+		wantOK     bool
+	}{
+		{
+			name:       "no cname record",
+			fqdn:       "_acme-challenge.example.com.",
+			lookupName: "_acme-challenge.example.com.",
+			wantOK:     false,
+		},
+		{
+			name:      "lookup error",
+			fqdn:      "_acme-challenge.example.com.",
+			lookupErr: errors.New("no such host"),
+			wantOK:    false,
+		},
+		{
+			name:       "delegated without suffix constraint",
+			fqdn:       "_acme-challenge.example.com.",
+			lookupName: "_acme-challenge.example.com.acme.internal.",
+			wantTarget: "_acme-challenge.example.com.acme.internal.",
+			wantOK:     true,
+		},
+		{
+			name:       "delegated matching suffix without trailing dot",
+			fqdn:       "_acme-challenge.example.com.",
+			suffix:     "acme.internal",
+			lookupName: "_acme-challenge.example.com.acme.internal.",
+			wantTarget: "_acme-challenge.example.com.acme.internal.",
+			wantOK:     true,
+		},
+		{
+			name:       "delegated matching suffix with trailing dot",
+			fqdn:       "_acme-challenge.example.com.",
+			suffix:     "acme.internal.",
+			lookupName: "_acme-challenge.example.com.acme.internal.",
+			wantTarget: "_acme-challenge.example.com.acme.internal.",
+			wantOK:     true,
+		},
+		{
+			name:       "delegated to an unexpected zone is rejected",
+			fqdn:       "_acme-challenge.example.com.",
+			suffix:     "acme.internal.",
+			lookupName: "_acme-challenge.example.com.other.invalid.",
+			wantOK:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := lookupCNAME
+			lookupCNAME = func(host string) (string, error) {
+				if tt.lookupErr != nil {
+					return "", tt.lookupErr
+				}
+				return tt.lookupName, nil
+			}
+			defer func() { lookupCNAME = original }()
+
+			target, ok := delegatedCNAMETarget(tt.fqdn, tt.suffix)
+			if ok != tt.wantOK {
+				t.Fatalf("delegatedCNAMETarget(%q, %q) ok = %v, want %v", tt.fqdn, tt.suffix, ok, tt.wantOK)
+			}
+			if ok && target != tt.wantTarget {
+				t.Fatalf("delegatedCNAMETarget(%q, %q) = %q, want %q", tt.fqdn, tt.suffix, target, tt.wantTarget)
+			}
+		})
+	}
+}
+
+func TestResolveChallengeTargetCNAMEConfirmationRequired(t *testing.T) {
+	ch := &v1alpha1.ChallengeRequest{ResolvedFQDN: "_acme-challenge.example.com."}
+
+	original := lookupCNAME
+	defer func() { lookupCNAME = original }()
+
+	t.Run("lookup failure is a hard error, not a fallback to the apex zone", func(t *testing.T) {
+		lookupCNAME = func(host string) (string, error) {
+			return "", errors.New("no such host")
+		}
+
+		c := &designateSolver{}
+		cfg := &designateConfig{CNAMEZoneID: "delegated-zone"}
+
+		_, _, err := c.resolveChallengeTarget(context.Background(), nil, cfg, ch)
+		if err == nil {
+			t.Fatal("resolveChallengeTarget() error = nil, want error when the CNAME cannot be confirmed")
+		}
+	})
+
+	t.Run("confirmed delegation targets the delegated zone", func(t *testing.T) {
+		lookupCNAME = func(host string) (string, error) {
+			return "_acme-challenge.example.com.acme.internal.", nil
+		}
+
+		c := &designateSolver{}
+		cfg := &designateConfig{CNAMEZoneID: "delegated-zone"}
+
+		target, zoneID, err := c.resolveChallengeTarget(context.Background(), nil, cfg, ch)
+		if err != nil {
+			t.Fatalf("resolveChallengeTarget() error = %s", err)
+		}
+		if zoneID != "delegated-zone" {
+			t.Fatalf("resolveChallengeTarget() zoneID = %q, want %q", zoneID, "delegated-zone")
+		}
+		if target != "_acme-challenge.example.com.acme.internal." {
+			t.Fatalf("resolveChallengeTarget() target = %q, want %q", target, "_acme-challenge.example.com.acme.internal.")
+		}
+	})
+}
+
+func TestEnsureTrailingDot(t *testing.T) {
+	tests := map[string]string{
+		"example.com":  "example.com.",
+		"example.com.": "example.com.",
+		"":             ".",
+	}
+
+	for in, want := range tests {
+		if got := ensureTrailingDot(in); got != want {
+			t.Fatalf("ensureTrailingDot(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -2,21 +2,54 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	extapi "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
 	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
 	"github.com/cert-manager/cert-manager/pkg/acme/webhook/cmd"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
 	"github.com/gophercloud/gophercloud/v2"
 	"github.com/gophercloud/gophercloud/v2/openstack"
 	"github.com/gophercloud/gophercloud/v2/openstack/config"
 	"github.com/gophercloud/gophercloud/v2/openstack/dns/v2/recordsets"
+	"github.com/gophercloud/gophercloud/v2/openstack/dns/v2/zones"
 )
 
+// zoneCacheTTL bounds how long an FQDN-to-zoneID lookup is reused before the
+// solver re-queries Designate, so that a zone added or removed from the
+// tenant is picked up within a bounded time.
+const zoneCacheTTL = 5 * time.Minute
+
+// defaultTTL is used for created TXT records when designateConfig.TTL is unset.
+const defaultTTL = 600
+
+// defaultPropagationTimeout bounds how long Present waits for a written
+// recordset to reach Designate's ACTIVE status when PropagationTimeout is
+// unset.
+const defaultPropagationTimeout = 2 * time.Minute
+
+// recordsetPollInterval is how often Present polls Designate while waiting
+// for a recordset to become ACTIVE.
+const recordsetPollInterval = 2 * time.Second
+
+// clientCacheTTL bounds how long a cached Designate client is reused before
+// it is rebuilt from scratch, kept comfortably below Keystone's typical 1h
+// token lifetime so a client is never reused past its token's expiry.
+const clientCacheTTL = 30 * time.Minute
+
 var GroupName = os.Getenv("GROUP_NAME")
 
 func main() {
@@ -39,14 +72,49 @@ func main() {
 // To do so, it must implement the `github.com/cert-manager/cert-manager/pkg/acme/webhook.Solver`
 // interface.
 type designateSolver struct {
-	// If a Kubernetes 'clientset' is needed, you must:
-	// 1. uncomment the additional `client` field in this structure below
-	// 2. uncomment the "k8s.io/client-go/kubernetes" import at the top of the file
-	// 3. uncomment the relevant code in the Initialize method below
-	// 4. ensure your webhook's service account has the required RBAC role
-	//    assigned to it for interacting with the Kubernetes APIs you need.
-	//client kubernetes.Clientset
-	dnsClient *gophercloud.ServiceClient
+	kubeConfig *rest.Config
+	client     *kubernetes.Clientset
+
+	zoneCacheMu sync.Mutex
+	zoneCache   map[string]zoneCacheEntry
+
+	clientMu    sync.Mutex
+	clientCache map[credHash]clientCacheEntry
+}
+
+// clientCacheEntry is a cached, authenticated Designate client along with
+// the time at which the solver should stop trusting its token and rebuild it.
+type clientCacheEntry struct {
+	client  *gophercloud.ServiceClient
+	expires time.Time
+}
+
+// zoneCacheEntry is a cached FQDN-to-zoneID resolution.
+type zoneCacheEntry struct {
+	zoneID  string
+	expires time.Time
+}
+
+// credHash identifies a resolved set of OpenStack auth options, so that
+// Present/CleanUp calls sharing the same issuer credentials reuse a single
+// authenticated Designate client instead of re-authenticating every time.
+type credHash string
+
+// hashAuthOptions must include every field that determines the authenticated
+// identity Designate sees, not just the ones that are usually set: two
+// issuers authenticating via application credentials typically leave
+// TenantID/Username/TenantName blank, and domain-scoped project/user names
+// are only unique within their domain, not globally. Omitting any of these
+// (or the secret itself) would let two distinct tenants collapse onto the
+// same cache key and share one tenant's authenticated client.
+func hashAuthOptions(opts gophercloud.AuthOptions, region string) credHash {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%s|%s|%s|%s|%s|%s|%s|%s",
+		opts.IdentityEndpoint, region,
+		opts.TenantID, opts.TenantName, opts.DomainID, opts.DomainName,
+		opts.UserID, opts.Username,
+		opts.ApplicationCredentialID, opts.ApplicationCredentialName,
+		opts.Password)))
+	return credHash(fmt.Sprintf("%x", sum))
 }
 
 // designateConfig is a structure that is used to decode into when
@@ -64,14 +132,58 @@ type designateSolver struct {
 // be used by your provider here, you should reference a Kubernetes Secret
 // resource and fetch these credentials using a Kubernetes clientset.
 type designateConfig struct {
-	// Change the two fields below according to the format of the configuration
-	// to be decoded.
-	// These fields will be set by users in the
-	// `issuer.spec.acme.dns01.providers.webhook.config` field.
-
-	//Email           string `json:"email"`
-	//APIKeySecretRef v1alpha1.SecretKeySelector `json:"apiKeySecretRef"`
-	ZoneID string `json:"zone_id"`
+	// ZoneID pins the Designate zone to use, bypassing auto-discovery.
+	// ZoneName restricts auto-discovery to a single, already-known zone name.
+	// When both are empty, the zone is discovered from ch.ResolvedFQDN.
+	ZoneID   string `json:"zone_id"`
+	ZoneName string `json:"zoneName"`
+
+	// AuthURL, Region and the project/domain/user identifiers below allow
+	// each Issuer/ClusterIssuer to authenticate against its own OpenStack
+	// cloud and project. When AuthURL is left empty, the solver falls back
+	// to OS_* environment variables, preserving single-tenant deployments.
+	AuthURL string `json:"authURL"`
+	Region  string `json:"region"`
+
+	ProjectID   string `json:"projectID"`
+	ProjectName string `json:"projectName"`
+	DomainID    string `json:"domainID"`
+	DomainName  string `json:"domainName"`
+	UserID      string `json:"userID"`
+	Username    string `json:"username"`
+
+	ApplicationCredentialID   string `json:"applicationCredentialID"`
+	ApplicationCredentialName string `json:"applicationCredentialName"`
+
+	// Password can either be set inline (discouraged) or resolved from a
+	// Kubernetes Secret referenced by PasswordSecretRef. The secret is read
+	// from ch.ResourceNamespace, the same namespace cert-manager uses to
+	// resolve other provider secrets. The referenced key can hold either an
+	// OpenStack user password or an application credential secret.
+	Password          string                   `json:"password"`
+	PasswordSecretRef cmmeta.SecretKeySelector `json:"passwordSecretRef"`
+
+	// CNAMEZoneID, when set, lets operators delegate _acme-challenge.<name>
+	// to a dedicated, lower-privilege Designate zone via a CNAME, keeping
+	// production zones read-only. When the CNAME chain for ch.ResolvedFQDN
+	// terminates in a name matching CNAMESuffix (or any name, if CNAMESuffix
+	// is empty), the challenge TXT record is written to/removed from
+	// CNAMEZoneID at the terminal name instead of the apex zone.
+	CNAMEZoneID string `json:"cnameZoneID"`
+	CNAMESuffix string `json:"cnameSuffix"`
+
+	// TTL overrides the default 600s TTL of the created TXT record.
+	TTL int `json:"ttl"`
+
+	// DescriptionTemplate overrides the default recordset description. It is
+	// expanded as a Go text/template with .DNSName, .ResolvedFQDN, .Key and
+	// .ResourceNamespace.
+	DescriptionTemplate string `json:"descriptionTemplate"`
+
+	// PropagationTimeout bounds how long Present waits for Designate to mark
+	// the written recordset ACTIVE before giving up, e.g. "90s". Defaults to
+	// defaultPropagationTimeout.
+	PropagationTimeout string `json:"propagationTimeout"`
 }
 
 // Name is used as the name for this DNS solver when referencing it on the ACME
@@ -84,21 +196,170 @@ func (c *designateSolver) Name() string {
 	return "designate-solver"
 }
 
-func (c *designateSolver) recordExists(name string, cfg *designateConfig) (*recordsets.RecordSet, error) {
+// resolveZoneID returns the Designate zone to use for fqdn. cfg.ZoneID, when
+// set, is always used as-is. Otherwise cfg.ZoneName pins the lookup to a
+// single known zone name, and failing that the zone is auto-discovered by
+// walking fqdn upward label-by-label and picking the longest-matching zone
+// the tenant owns. Successful discoveries are cached for zoneCacheTTL so that
+// repeated challenges for the same name don't re-query Designate every time.
+func (c *designateSolver) resolveZoneID(ctx context.Context, dnsClient *gophercloud.ServiceClient, cfg *designateConfig, fqdn string) (string, error) {
+	if cfg.ZoneID != "" {
+		return cfg.ZoneID, nil
+	}
+
+	if cfg.ZoneName != "" {
+		return zoneIDForName(ctx, dnsClient, cfg.ZoneName)
+	}
+
+	if zoneID, ok := c.cachedZoneID(fqdn); ok {
+		return zoneID, nil
+	}
+
+	zoneID, err := discoverZoneID(ctx, dnsClient, fqdn)
+	if err != nil {
+		return "", err
+	}
+
+	c.cacheZoneID(fqdn, zoneID)
+	return zoneID, nil
+}
+
+// resolveChallengeTarget returns the DNS name and Designate zone that the
+// challenge TXT record should actually be written to/removed from. When
+// cfg.CNAMEZoneID is set, _acme-challenge.<name> is required to be
+// CNAME-delegated to a matching name: the delegated zone is the only place
+// the record has ever been written, so a CNAME that cannot be confirmed is
+// treated as a hard error rather than falling back to the apex zone, where
+// CleanUp would find nothing to delete and silently leak the record.
+func (c *designateSolver) resolveChallengeTarget(ctx context.Context, dnsClient *gophercloud.ServiceClient, cfg *designateConfig, ch *v1alpha1.ChallengeRequest) (string, string, error) {
+	if cfg.CNAMEZoneID != "" {
+		target, ok := delegatedCNAMETarget(ch.ResolvedFQDN, cfg.CNAMESuffix)
+		if !ok {
+			return "", "", fmt.Errorf("cnameZoneID is configured but could not confirm a CNAME delegation for %s", ch.ResolvedFQDN)
+		}
+		return target, cfg.CNAMEZoneID, nil
+	}
+
+	zoneID, err := c.resolveZoneID(ctx, dnsClient, cfg, ch.ResolvedFQDN)
+	if err != nil {
+		return "", "", err
+	}
+	return ch.ResolvedFQDN, zoneID, nil
+}
+
+// lookupCNAME resolves the CNAME chain for a name. It is a variable, rather
+// than a direct call to net.LookupCNAME, so tests can stub DNS resolution.
+var lookupCNAME = net.LookupCNAME
+
+// delegatedCNAMETarget follows the CNAME chain for fqdn and, if it resolves
+// to a different terminal name, returns that name. When suffix is non-empty,
+// the terminal name must end with it, guarding against a misconfigured CNAME
+// pointing the challenge record at an unexpected zone.
+func delegatedCNAMETarget(fqdn, suffix string) (string, bool) {
+	cname, err := lookupCNAME(fqdn)
+	if err != nil || cname == "" || cname == fqdn {
+		return "", false
+	}
+
+	if suffix != "" && !strings.HasSuffix(cname, ensureTrailingDot(suffix)) {
+		return "", false
+	}
+
+	return cname, true
+}
+
+func ensureTrailingDot(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+func (c *designateSolver) cachedZoneID(fqdn string) (string, bool) {
+	c.zoneCacheMu.Lock()
+	defer c.zoneCacheMu.Unlock()
+
+	entry, ok := c.zoneCache[fqdn]
+	if !ok || time.Now().After(entry.expires) {
+		return "", false
+	}
+	return entry.zoneID, true
+}
+
+func (c *designateSolver) cacheZoneID(fqdn, zoneID string) {
+	c.zoneCacheMu.Lock()
+	defer c.zoneCacheMu.Unlock()
+
+	if c.zoneCache == nil {
+		c.zoneCache = make(map[string]zoneCacheEntry)
+	}
+	c.zoneCache[fqdn] = zoneCacheEntry{zoneID: zoneID, expires: time.Now().Add(zoneCacheTTL)}
+}
+
+// discoverZoneID walks fqdn upward label-by-label (e.g. "a.b.example.com.",
+// "b.example.com.", "example.com.") and returns the ID of the first, and
+// therefore longest-matching, zone the tenant owns.
+func discoverZoneID(ctx context.Context, dnsClient *gophercloud.ServiceClient, fqdn string) (string, error) {
+	labels := strings.Split(strings.TrimSuffix(fqdn, "."), ".")
+
+	for i := 0; i < len(labels)-1; i++ {
+		candidate := strings.Join(labels[i:], ".") + "."
+
+		zoneID, err := zoneIDForName(ctx, dnsClient, candidate)
+		if err == nil {
+			return zoneID, nil
+		}
+	}
+
+	return "", fmt.Errorf("no designate zone found for %s", fqdn)
+}
+
+// zoneIDForName looks up the ID of the zone with the exact given name.
+func zoneIDForName(ctx context.Context, dnsClient *gophercloud.ServiceClient, name string) (string, error) {
+	name = ensureTrailingDot(name)
+
+	pages, err := zones.List(dnsClient, zones.ListOpts{Name: name}).AllPages(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not list zones named %s : %s", name, err)
+	}
+
+	allZones, err := zones.ExtractZones(pages)
+	if err != nil {
+		return "", fmt.Errorf("error extracting zones : %s", err)
+	}
+
+	if len(allZones) == 0 {
+		return "", fmt.Errorf("no designate zone named %s", name)
+	}
+
+	return allZones[0].ID, nil
+}
+
+// recordsContain reports whether key is already present among records.
+func recordsContain(records []string, key string) bool {
+	for _, r := range records {
+		if r == key {
+			return true
+		}
+	}
+	return false
+}
+
+func recordExists(dnsClient *gophercloud.ServiceClient, name, zoneID string) (*recordsets.RecordSet, error) {
 
 	listOptions := recordsets.ListOpts{
 		Type: "TXT",
 		Name: name,
 	}
 
-	pages, err := recordsets.ListByZone(c.dnsClient, cfg.ZoneID, listOptions).AllPages(context.TODO())
+	pages, err := recordsets.ListByZone(dnsClient, zoneID, listOptions).AllPages(context.TODO())
 	if err != nil {
-		return nil, fmt.Errorf("Could not list records by zone : %s", err)
+		return nil, fmt.Errorf("could not list records by zone : %s", err)
 	}
 
 	allRecords, err := recordsets.ExtractRecordSets(pages)
 	if err != nil {
-		return nil, fmt.Errorf("Error extracting pages : %s", err)
+		return nil, fmt.Errorf("error extracting pages : %s", err)
 	}
 
 	if len(allRecords) > 0 {
@@ -108,6 +369,252 @@ func (c *designateSolver) recordExists(name string, cfg *designateConfig) (*reco
 	}
 }
 
+// resolvePassword returns the password/application-credential-secret to use
+// for authentication, preferring an explicit PasswordSecretRef over the
+// inline Password field.
+func (c *designateSolver) resolvePassword(ctx context.Context, cfg *designateConfig, namespace string) (string, error) {
+	if cfg.PasswordSecretRef.Name == "" {
+		return cfg.Password, nil
+	}
+
+	if c.client == nil {
+		return "", fmt.Errorf("cannot resolve passwordSecretRef %s/%s : no kubernetes client configured", namespace, cfg.PasswordSecretRef.Name)
+	}
+
+	secret, err := c.client.CoreV1().Secrets(namespace).Get(ctx, cfg.PasswordSecretRef.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("could not get secret %s/%s : %s", namespace, cfg.PasswordSecretRef.Name, err)
+	}
+
+	key := cfg.PasswordSecretRef.Key
+	value, ok := secret.Data[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s/%s does not contain key %q", namespace, cfg.PasswordSecretRef.Name, key)
+	}
+
+	return string(value), nil
+}
+
+// authOptionsFor builds the gophercloud.AuthOptions to use for ch, preferring
+// the per-issuer configuration in cfg and falling back to OS_* environment
+// variables when AuthURL is not set, so that existing single-tenant
+// deployments keep working unchanged.
+func (c *designateSolver) authOptionsFor(ctx context.Context, cfg *designateConfig, ch *v1alpha1.ChallengeRequest) (gophercloud.AuthOptions, error) {
+	if cfg.AuthURL == "" {
+		return openstack.AuthOptionsFromEnv()
+	}
+
+	password, err := c.resolvePassword(ctx, cfg, ch.ResourceNamespace)
+	if err != nil {
+		return gophercloud.AuthOptions{}, err
+	}
+
+	return gophercloud.AuthOptions{
+		IdentityEndpoint:            cfg.AuthURL,
+		TenantID:                    cfg.ProjectID,
+		TenantName:                  cfg.ProjectName,
+		DomainID:                    cfg.DomainID,
+		DomainName:                  cfg.DomainName,
+		UserID:                      cfg.UserID,
+		Username:                    cfg.Username,
+		Password:                    password,
+		ApplicationCredentialID:     cfg.ApplicationCredentialID,
+		ApplicationCredentialName:   cfg.ApplicationCredentialName,
+		ApplicationCredentialSecret: password,
+	}, nil
+}
+
+func (c *designateSolver) regionFor(cfg *designateConfig) string {
+	if cfg.Region != "" {
+		return cfg.Region
+	}
+	return os.Getenv("OS_REGION_NAME")
+}
+
+func ttlFor(cfg *designateConfig) int {
+	if cfg.TTL > 0 {
+		return cfg.TTL
+	}
+	return defaultTTL
+}
+
+func propagationTimeoutFor(cfg *designateConfig) time.Duration {
+	if cfg.PropagationTimeout == "" {
+		return defaultPropagationTimeout
+	}
+
+	d, err := time.ParseDuration(cfg.PropagationTimeout)
+	if err != nil {
+		return defaultPropagationTimeout
+	}
+	return d
+}
+
+// descriptionFor renders the recordset description, expanding
+// cfg.DescriptionTemplate when set and falling back to the historical
+// "The acme record for <DNSName>" description otherwise.
+func descriptionFor(cfg *designateConfig, ch *v1alpha1.ChallengeRequest) (string, error) {
+	if cfg.DescriptionTemplate == "" {
+		return fmt.Sprintf("The acme record for %s", ch.DNSName), nil
+	}
+
+	tmpl, err := template.New("description").Parse(cfg.DescriptionTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid descriptionTemplate : %s", err)
+	}
+
+	var buf strings.Builder
+	err = tmpl.Execute(&buf, struct {
+		DNSName           string
+		ResolvedFQDN      string
+		Key               string
+		ResourceNamespace string
+	}{
+		DNSName:           ch.DNSName,
+		ResolvedFQDN:      ch.ResolvedFQDN,
+		Key:               ch.Key,
+		ResourceNamespace: ch.ResourceNamespace,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not render descriptionTemplate : %s", err)
+	}
+
+	return buf.String(), nil
+}
+
+// waitForActive polls the recordset until it reaches Designate's ACTIVE
+// status or timeout elapses. cert-manager's self-check can otherwise race
+// Designate's worker and trip a spurious challenge failure on slow backends.
+func waitForActive(ctx context.Context, dnsClient *gophercloud.ServiceClient, zoneID, recordID string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		rr, err := recordsets.Get(ctx, dnsClient, zoneID, recordID).Extract()
+		if err != nil {
+			return fmt.Errorf("could not get record %s in zone %s : %s", recordID, zoneID, err)
+		}
+
+		if rr.Status == "ACTIVE" {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for record %s in zone %s to become ACTIVE, last status was %s", recordID, zoneID, rr.Status)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(recordsetPollInterval):
+		}
+	}
+}
+
+// dnsClientFor returns an authenticated Designate client for the credentials
+// resolved from cfg/ch, along with the cache key identifying those
+// credentials. It reuses a cached client while its token is still within
+// clientCacheTTL, and rebuilds one otherwise. This avoids hitting Keystone on
+// every Present/CleanUp call, and avoids the nil dnsClient that a webhook
+// restart between Present and CleanUp used to cause.
+func (c *designateSolver) dnsClientFor(ctx context.Context, cfg *designateConfig, ch *v1alpha1.ChallengeRequest) (*gophercloud.ServiceClient, credHash, error) {
+	authOptions, err := c.authOptionsFor(ctx, cfg, ch)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not load config : %s", err)
+	}
+
+	region := c.regionFor(cfg)
+	key := hashAuthOptions(authOptions, region)
+
+	if client, ok := c.cachedDNSClient(key); ok {
+		return client, key, nil
+	}
+
+	dnsClient, err := c.newDNSClient(ctx, authOptions, region)
+	if err != nil {
+		return nil, "", err
+	}
+
+	c.cacheDNSClient(key, dnsClient)
+	return dnsClient, key, nil
+}
+
+func (c *designateSolver) newDNSClient(ctx context.Context, authOptions gophercloud.AuthOptions, region string) (*gophercloud.ServiceClient, error) {
+	providerClient, err := config.NewProviderClient(ctx, authOptions)
+	if err != nil {
+		return nil, fmt.Errorf("openstack provider config err : %s", err)
+	}
+
+	dnsClient, err := openstack.NewDNSV2(providerClient, gophercloud.EndpointOpts{Region: region})
+	if err != nil {
+		return nil, fmt.Errorf("error instantiating dnsv2 client : %s", err)
+	}
+
+	return dnsClient, nil
+}
+
+func (c *designateSolver) cachedDNSClient(key credHash) (*gophercloud.ServiceClient, bool) {
+	c.clientMu.Lock()
+	defer c.clientMu.Unlock()
+
+	entry, ok := c.clientCache[key]
+	if !ok || entry.client.ProviderClient.Token() == "" || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.client, true
+}
+
+func (c *designateSolver) cacheDNSClient(key credHash, client *gophercloud.ServiceClient) {
+	c.clientMu.Lock()
+	defer c.clientMu.Unlock()
+
+	if c.clientCache == nil {
+		c.clientCache = make(map[credHash]clientCacheEntry)
+	}
+	c.clientCache[key] = clientCacheEntry{client: client, expires: time.Now().Add(clientCacheTTL)}
+}
+
+// invalidateDNSClient drops a cached client, forcing the next dnsClientFor
+// call for that credential set to re-authenticate against Keystone.
+func (c *designateSolver) invalidateDNSClient(key credHash) {
+	c.clientMu.Lock()
+	defer c.clientMu.Unlock()
+
+	delete(c.clientCache, key)
+}
+
+// isUnauthorized reports whether err is Designate/Keystone rejecting the
+// request's token, i.e. the signal that the cached client must be evicted
+// and rebuilt rather than reused again.
+func isUnauthorized(err error) bool {
+	return gophercloud.ResponseCodeIs(err, http.StatusUnauthorized)
+}
+
+// withDNSClient resolves a Designate client for cfg/ch and runs fn with it.
+// If fn fails because the client's token was rejected, the cached client is
+// evicted and fn is retried once against a freshly authenticated client, so
+// a token that expired or was revoked between the cache check and the actual
+// Designate call doesn't permanently wedge Present/CleanUp.
+func (c *designateSolver) withDNSClient(ctx context.Context, cfg *designateConfig, ch *v1alpha1.ChallengeRequest, fn func(dnsClient *gophercloud.ServiceClient) error) error {
+	dnsClient, key, err := c.dnsClientFor(ctx, cfg, ch)
+	if err != nil {
+		return err
+	}
+
+	err = fn(dnsClient)
+	if err == nil || !isUnauthorized(err) {
+		return err
+	}
+
+	c.invalidateDNSClient(key)
+
+	dnsClient, _, err = c.dnsClientFor(ctx, cfg, ch)
+	if err != nil {
+		return err
+	}
+
+	return fn(dnsClient)
+}
+
 // Present is responsible for actually presenting the DNS record with the
 // DNS provider.
 // This method should tolerate being called multiple times with the same value.
@@ -119,63 +626,76 @@ func (c *designateSolver) Present(ch *v1alpha1.ChallengeRequest) error {
 		return err
 	}
 
-	// TODO: do something more useful with the decoded configuration
-	fmt.Printf("Decoded configuration %v", cfg)
-
-	authOptions, err := openstack.AuthOptionsFromEnv()
+	description, err := descriptionFor(&cfg, ch)
 	if err != nil {
-		return fmt.Errorf("Could not load config : %s", err)
+		return err
 	}
-	fmt.Printf("Loaded auth options\n")
 
-	client, err := config.NewProviderClient(context.Background(), authOptions)
-	if err != nil {
-		return fmt.Errorf("Openstack provider config err : %s", err)
-	}
+	return c.withDNSClient(context.Background(), &cfg, ch, func(dnsClient *gophercloud.ServiceClient) error {
+		targetName, zoneID, err := c.resolveChallengeTarget(context.Background(), dnsClient, &cfg, ch)
+		if err != nil {
+			return fmt.Errorf("could not resolve zone for %s : %s", ch.ResolvedFQDN, err)
+		}
 
-	c.dnsClient, err = openstack.NewDNSV2(client, gophercloud.EndpointOpts{
-		Region: os.Getenv("OS_REGION_NAME"),
+		return presentRecordset(context.Background(), dnsClient, targetName, zoneID, ch.Key, ttlFor(&cfg), description, propagationTimeoutFor(&cfg))
 	})
-	if err != nil {
-		return fmt.Errorf("Error instantiating dnsv2 client : %s", err)
-	}
+}
 
-	rr, err := c.recordExists(ch.ResolvedFQDN, &cfg)
+// presentRecordset creates the TXT recordset for targetName in zoneID if it
+// doesn't exist, or appends key to it if it does, then waits for the write
+// to reach Designate's ACTIVE status. It is factored out of Present so the
+// append/create branching can be exercised against a fake Designate server
+// without needing real OpenStack credentials.
+func presentRecordset(ctx context.Context, dnsClient *gophercloud.ServiceClient, targetName, zoneID, key string, ttl int, description string, propagationTimeout time.Duration) error {
+	rr, err := recordExists(dnsClient, targetName, zoneID)
 	if err != nil {
-		return fmt.Errorf("Could not check if record %s exists : %s", ch.ResolvedFQDN, err)
+		return fmt.Errorf("could not check if record %s exists : %s", targetName, err)
 	}
 
+	var recordID string
+
 	if rr != nil {
-		if len(rr.Records) == 1 && rr.Records[0] == ch.Key {
-			return nil
+		if recordsContain(rr.Records, key) {
+			// key is already written, but don't treat that as "already
+			// confirmed ACTIVE": a withDNSClient retry (e.g. after a 401 that
+			// happened while the first attempt was itself polling here) must
+			// still wait for Designate to settle before Present returns,
+			// rather than skipping straight past waitForActive.
+			return waitForActive(ctx, dnsClient, zoneID, rr.ID, propagationTimeout)
 		}
 
+		// Append key to the existing records instead of overwriting the
+		// slice, so that a parallel in-flight challenge for the same name
+		// (e.g. the wildcard and apex of a certificate) keeps its own TXT
+		// record around.
 		updateOpts := recordsets.UpdateOpts{
-			Records: []string{ch.Key},
+			Records: append(append([]string{}, rr.Records...), key),
 		}
 
-		err = recordsets.Update(context.TODO(), c.dnsClient, cfg.ZoneID, rr.ID, updateOpts).Err
+		err = recordsets.Update(ctx, dnsClient, zoneID, rr.ID, updateOpts).Err
 		if err != nil {
-			return fmt.Errorf("Could not update record : %s", err)
+			return fmt.Errorf("could not update record : %s", err)
 		}
+		recordID = rr.ID
 
 	} else {
 		// create record
 		createOpts := recordsets.CreateOpts{
-			Name:        ch.ResolvedFQDN,
+			Name:        targetName,
 			Type:        "TXT",
-			TTL:         600,
-			Description: fmt.Sprintf("The acme record for %s", ch.DNSName),
-			Records:     []string{ch.Key},
+			TTL:         ttl,
+			Description: description,
+			Records:     []string{key},
 		}
 
-		err = recordsets.Create(context.TODO(), c.dnsClient, cfg.ZoneID, createOpts).Err
+		created, err := recordsets.Create(ctx, dnsClient, zoneID, createOpts).Extract()
 		if err != nil {
-			return fmt.Errorf("Could not create record : %s", err)
+			return fmt.Errorf("could not create record : %s", err)
 		}
+		recordID = created.ID
 	}
 
-	return nil
+	return waitForActive(ctx, dnsClient, zoneID, recordID, propagationTimeout)
 }
 
 // CleanUp should delete the relevant TXT record from the DNS provider console.
@@ -185,30 +705,68 @@ func (c *designateSolver) Present(ch *v1alpha1.ChallengeRequest) error {
 // This is in order to facilitate multiple DNS validations for the same domain
 // concurrently.
 func (c *designateSolver) CleanUp(ch *v1alpha1.ChallengeRequest) error {
-	// TODO: add code that deletes a record from the DNS provider's console
 	cfg, err := loadConfig(ch.Config)
 	if err != nil {
 		return err
 	}
 
+	return c.withDNSClient(context.Background(), &cfg, ch, func(dnsClient *gophercloud.ServiceClient) error {
+		targetName, zoneID, err := c.resolveChallengeTarget(context.Background(), dnsClient, &cfg, ch)
+		if err != nil {
+			return fmt.Errorf("could not resolve zone for %s : %s", ch.ResolvedFQDN, err)
+		}
+
+		return cleanupRecordset(context.Background(), dnsClient, targetName, zoneID, ch.Key)
+	})
+}
+
+// cleanupRecordset removes key from every TXT recordset named targetName in
+// zoneID: the matching value is stripped from each recordset's Records, and
+// the recordset is updated with what remains, or deleted outright if nothing
+// remains. It is factored out of CleanUp so this partial-removal branching
+// can be exercised against a fake Designate server without needing real
+// OpenStack credentials.
+func cleanupRecordset(ctx context.Context, dnsClient *gophercloud.ServiceClient, targetName, zoneID, key string) error {
 	listOptions := recordsets.ListOpts{
 		Type: "TXT",
-		Name: ch.ResolvedFQDN,
+		Name: targetName,
 	}
 
-	pages, err := recordsets.ListByZone(c.dnsClient, cfg.ZoneID, listOptions).AllPages(context.TODO())
+	pages, err := recordsets.ListByZone(dnsClient, zoneID, listOptions).AllPages(ctx)
 	if err != nil {
-		return fmt.Errorf("Could not list records by zone : %s", err)
+		return fmt.Errorf("could not list records by zone : %s", err)
 	}
 
 	allRecords, err := recordsets.ExtractRecordSets(pages)
 	if err != nil {
-		return fmt.Errorf("Error extracting pages : %s", err)
+		return fmt.Errorf("error extracting pages : %s", err)
 	}
 
 	for _, rec := range allRecords {
-		if err = recordsets.Delete(context.Background(), c.dnsClient, rec.ZoneID, rec.ID).ExtractErr(); err != nil {
-			return fmt.Errorf("Could not delete record %s in zone %s : %s", rec.ID, rec.ZoneID, err)
+		if !recordsContain(rec.Records, key) {
+			continue
+		}
+
+		remaining := make([]string, 0, len(rec.Records))
+		for _, value := range rec.Records {
+			if value != key {
+				remaining = append(remaining, value)
+			}
+		}
+
+		if len(remaining) == 0 {
+			if err = recordsets.Delete(ctx, dnsClient, rec.ZoneID, rec.ID).ExtractErr(); err != nil {
+				return fmt.Errorf("could not delete record %s in zone %s : %s", rec.ID, rec.ZoneID, err)
+			}
+			continue
+		}
+
+		updateOpts := recordsets.UpdateOpts{
+			Records: remaining,
+		}
+
+		if err = recordsets.Update(ctx, dnsClient, rec.ZoneID, rec.ID, updateOpts).Err; err != nil {
+			return fmt.Errorf("could not update record %s in zone %s : %s", rec.ID, rec.ZoneID, err)
 		}
 	}
 
@@ -225,17 +783,14 @@ func (c *designateSolver) CleanUp(ch *v1alpha1.ChallengeRequest) error {
 // The stopCh can be used to handle early termination of the webhook, in cases
 // where a SIGTERM or similar signal is sent to the webhook process.
 func (c *designateSolver) Initialize(kubeClientConfig *rest.Config, stopCh <-chan struct{}) error {
-	///// UNCOMMENT THE BELOW CODE TO MAKE A KUBERNETES CLIENTSET AVAILABLE TO
-	///// YOUR CUSTOM DNS PROVIDER
+	cl, err := kubernetes.NewForConfig(kubeClientConfig)
+	if err != nil {
+		return err
+	}
 
-	//cl, err := kubernetes.NewForConfig(kubeClientConfig)
-	//if err != nil {
-	//	return err
-	//}
-	//
-	//c.client = cl
+	c.kubeConfig = kubeClientConfig
+	c.client = cl
 
-	///// END OF CODE TO MAKE KUBERNETES CLIENTSET AVAILABLE
 	return nil
 }
 
@@ -245,7 +800,7 @@ func loadConfig(cfgJSON *extapi.JSON) (designateConfig, error) {
 	cfg := designateConfig{}
 	// handle the 'base case' where no configuration has been provided
 	if cfgJSON == nil {
-		return designateConfig{}, fmt.Errorf("Missing zone_id field")
+		return designateConfig{}, fmt.Errorf("missing solver config")
 	}
 
 	if err := json.Unmarshal(cfgJSON.Raw, &cfg); err != nil {
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
+	"github.com/gophercloud/gophercloud/v2"
+)
+
+func TestTTLFor(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  int
+		want int
+	}{
+		{name: "positive ttl is used as-is", ttl: 120, want: 120},
+		{name: "zero ttl falls back to default", ttl: 0, want: defaultTTL},
+		{name: "negative ttl falls back to default", ttl: -1, want: defaultTTL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &designateConfig{TTL: tt.ttl}
+			if got := ttlFor(cfg); got != tt.want {
+				t.Fatalf("ttlFor(%+v) = %d, want %d", cfg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPropagationTimeoutFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout string
+		want    time.Duration
+	}{
+		{name: "empty uses default", timeout: "", want: defaultPropagationTimeout},
+		{name: "invalid duration falls back to default", timeout: "not-a-duration", want: defaultPropagationTimeout},
+		{name: "valid duration is parsed", timeout: "90s", want: 90 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &designateConfig{PropagationTimeout: tt.timeout}
+			if got := propagationTimeoutFor(cfg); got != tt.want {
+				t.Fatalf("propagationTimeoutFor(%+v) = %s, want %s", cfg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescriptionFor(t *testing.T) {
+	ch := &v1alpha1.ChallengeRequest{
+		DNSName:           "example.com",
+		ResolvedFQDN:      "_acme-challenge.example.com.",
+		Key:               "challenge-key",
+		ResourceNamespace: "default",
+	}
+
+	t.Run("no template uses the historical description", func(t *testing.T) {
+		cfg := &designateConfig{}
+		got, err := descriptionFor(cfg, ch)
+		if err != nil {
+			t.Fatalf("descriptionFor() error = %s", err)
+		}
+		want := "The acme record for example.com"
+		if got != want {
+			t.Fatalf("descriptionFor() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("template renders challenge fields", func(t *testing.T) {
+		cfg := &designateConfig{DescriptionTemplate: "{{.DNSName}} in {{.ResourceNamespace}}"}
+		got, err := descriptionFor(cfg, ch)
+		if err != nil {
+			t.Fatalf("descriptionFor() error = %s", err)
+		}
+		want := "example.com in default"
+		if got != want {
+			t.Fatalf("descriptionFor() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("malformed template returns an error", func(t *testing.T) {
+		cfg := &designateConfig{DescriptionTemplate: "{{.DNSName"}
+		if _, err := descriptionFor(cfg, ch); err == nil {
+			t.Fatal("descriptionFor() error = nil, want error for malformed template")
+		}
+	})
+
+	t.Run("template referencing an unknown field returns an error", func(t *testing.T) {
+		cfg := &designateConfig{DescriptionTemplate: "{{.NoSuchField}}"}
+		if _, err := descriptionFor(cfg, ch); err == nil {
+			t.Fatal("descriptionFor() error = nil, want error for unknown field")
+		}
+	})
+}
+
+func TestWaitForActive(t *testing.T) {
+	t.Run("returns nil once the recordset is ACTIVE", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "rr-1", "status": "ACTIVE"})
+		}))
+		defer ts.Close()
+
+		dnsClient := &gophercloud.ServiceClient{
+			ProviderClient: &gophercloud.ProviderClient{},
+			Endpoint:       ts.URL + "/",
+		}
+
+		err := waitForActive(context.Background(), dnsClient, "zone-1", "rr-1", time.Second)
+		if err != nil {
+			t.Fatalf("waitForActive() error = %s", err)
+		}
+	})
+
+	t.Run("times out while the recordset stays PENDING", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "rr-1", "status": "PENDING"})
+		}))
+		defer ts.Close()
+
+		dnsClient := &gophercloud.ServiceClient{
+			ProviderClient: &gophercloud.ProviderClient{},
+			Endpoint:       ts.URL + "/",
+		}
+
+		err := waitForActive(context.Background(), dnsClient, "zone-1", "rr-1", time.Nanosecond)
+		if err == nil {
+			t.Fatal("waitForActive() error = nil, want timeout error")
+		}
+	})
+
+	t.Run("returns an error when the recordset cannot be fetched", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer ts.Close()
+
+		dnsClient := &gophercloud.ServiceClient{
+			ProviderClient: &gophercloud.ProviderClient{},
+			Endpoint:       ts.URL + "/",
+		}
+
+		err := waitForActive(context.Background(), dnsClient, "zone-1", "rr-1", time.Second)
+		if err == nil {
+			t.Fatal("waitForActive() error = nil, want error for failed fetch")
+		}
+	})
+}
+
+// fakeRecordsetServer returns an httptest server that answers the list
+// endpoint with allRecords and dispatches writes to onUpdate/onDelete, so
+// tests can drive cleanupRecordset/presentRecordset without a live Designate.
+// A GET whose path doesn't end in "/recordsets" is treated as the
+// recordsets.Get poll waitForActive issues after a write, and is answered
+// ACTIVE so it doesn't need its own case in every test.
+func fakeRecordsetServer(t *testing.T, allRecords []map[string]any, onUpdate func(id string, records []string), onDelete func(id string)) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/recordsets"):
+			_ = json.NewEncoder(w).Encode(map[string]any{"recordsets": allRecords})
+
+		case r.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "rr-1", "status": "ACTIVE"})
+
+		case r.Method == http.MethodPut:
+			id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+			var body struct {
+				Records []string `json:"records"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if onUpdate != nil {
+				onUpdate(id, body.Records)
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": id, "records": body.Records, "status": "ACTIVE"})
+
+		case r.Method == http.MethodDelete:
+			id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+			if onDelete != nil {
+				onDelete(id)
+			}
+			w.WriteHeader(http.StatusAccepted)
+
+		case r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "rr-new", "status": "ACTIVE"})
+
+		default:
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+}
+
+func fakeDNSClient(ts *httptest.Server) *gophercloud.ServiceClient {
+	return &gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{},
+		Endpoint:       ts.URL + "/",
+	}
+}
+
+func TestCleanupRecordset(t *testing.T) {
+	t.Run("removes only the matching value, leaving the rest of a concurrent challenge's record intact", func(t *testing.T) {
+		var updated []string
+		ts := fakeRecordsetServer(t, []map[string]any{
+			{"id": "rr-1", "zone_id": "zone-1", "records": []string{"apex-key", "wildcard-key"}},
+		}, func(id string, records []string) { updated = records }, nil)
+		defer ts.Close()
+
+		err := cleanupRecordset(context.Background(), fakeDNSClient(ts), "_acme-challenge.example.com.", "zone-1", "apex-key")
+		if err != nil {
+			t.Fatalf("cleanupRecordset() error = %s", err)
+		}
+
+		want := []string{"wildcard-key"}
+		if !reflect.DeepEqual(updated, want) {
+			t.Fatalf("cleanupRecordset() left records = %v, want %v", updated, want)
+		}
+	})
+
+	t.Run("deletes the recordset once its last value is removed", func(t *testing.T) {
+		var deletedID string
+		ts := fakeRecordsetServer(t, []map[string]any{
+			{"id": "rr-1", "zone_id": "zone-1", "records": []string{"apex-key"}},
+		}, nil, func(id string) { deletedID = id })
+		defer ts.Close()
+
+		err := cleanupRecordset(context.Background(), fakeDNSClient(ts), "_acme-challenge.example.com.", "zone-1", "apex-key")
+		if err != nil {
+			t.Fatalf("cleanupRecordset() error = %s", err)
+		}
+
+		if deletedID != "rr-1" {
+			t.Fatalf("cleanupRecordset() deleted id = %q, want %q", deletedID, "rr-1")
+		}
+	})
+
+	t.Run("ignores recordsets that don't contain the key", func(t *testing.T) {
+		ts := fakeRecordsetServer(t, []map[string]any{
+			{"id": "rr-1", "zone_id": "zone-1", "records": []string{"other-key"}},
+		}, func(id string, records []string) {
+			t.Fatalf("unexpected update of recordset %s", id)
+		}, func(id string) {
+			t.Fatalf("unexpected delete of recordset %s", id)
+		})
+		defer ts.Close()
+
+		err := cleanupRecordset(context.Background(), fakeDNSClient(ts), "_acme-challenge.example.com.", "zone-1", "apex-key")
+		if err != nil {
+			t.Fatalf("cleanupRecordset() error = %s", err)
+		}
+	})
+}
+
+func TestPresentRecordset(t *testing.T) {
+	t.Run("appends to an existing recordset instead of overwriting it", func(t *testing.T) {
+		var updated []string
+		ts := fakeRecordsetServer(t, []map[string]any{
+			{"id": "rr-1", "zone_id": "zone-1", "records": []string{"wildcard-key"}},
+		}, func(id string, records []string) { updated = records }, nil)
+		defer ts.Close()
+
+		err := presentRecordset(context.Background(), fakeDNSClient(ts), "_acme-challenge.example.com.", "zone-1", "apex-key", defaultTTL, "desc", time.Second)
+		if err != nil {
+			t.Fatalf("presentRecordset() error = %s", err)
+		}
+
+		want := []string{"wildcard-key", "apex-key"}
+		if !reflect.DeepEqual(updated, want) {
+			t.Fatalf("presentRecordset() records = %v, want %v", updated, want)
+		}
+	})
+
+	t.Run("still waits for ACTIVE, without rewriting, once the key is already present", func(t *testing.T) {
+		ts := fakeRecordsetServer(t, []map[string]any{
+			{"id": "rr-1", "zone_id": "zone-1", "records": []string{"apex-key"}},
+		}, func(id string, records []string) {
+			t.Fatalf("unexpected update of recordset %s", id)
+		}, nil)
+		defer ts.Close()
+
+		err := presentRecordset(context.Background(), fakeDNSClient(ts), "_acme-challenge.example.com.", "zone-1", "apex-key", defaultTTL, "desc", time.Second)
+		if err != nil {
+			t.Fatalf("presentRecordset() error = %s", err)
+		}
+	})
+
+	t.Run("reports a timeout when already-present key never reaches ACTIVE", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if strings.HasSuffix(r.URL.Path, "/recordsets") {
+				_ = json.NewEncoder(w).Encode(map[string]any{
+					"recordsets": []map[string]any{
+						{"id": "rr-1", "zone_id": "zone-1", "records": []string{"apex-key"}},
+					},
+				})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "rr-1", "status": "PENDING"})
+		}))
+		defer ts.Close()
+
+		err := presentRecordset(context.Background(), fakeDNSClient(ts), "_acme-challenge.example.com.", "zone-1", "apex-key", defaultTTL, "desc", time.Nanosecond)
+		if err == nil {
+			t.Fatal("presentRecordset() error = nil, want timeout error when an already-present key hasn't reached ACTIVE")
+		}
+	})
+
+	t.Run("creates a new recordset when none exists yet", func(t *testing.T) {
+		ts := fakeRecordsetServer(t, nil, nil, nil)
+		defer ts.Close()
+
+		err := presentRecordset(context.Background(), fakeDNSClient(ts), "_acme-challenge.example.com.", "zone-1", "apex-key", defaultTTL, "desc", time.Second)
+		if err != nil {
+			t.Fatalf("presentRecordset() error = %s", err)
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeZonesServer answers zones.List requests, returning the zones in
+// byName whose name matches the "name" query parameter, and records every
+// queried name in queried so tests can assert which candidates were tried.
+func fakeZonesServer(t *testing.T, byName map[string]string, queried *[]string) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+		if queried != nil {
+			*queried = append(*queried, name)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := byName[name]
+		if !ok {
+			_ = json.NewEncoder(w).Encode(map[string]any{"zones": []map[string]any{}})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"zones": []map[string]any{{"id": id, "name": name}},
+		})
+	}))
+}
+
+func TestZoneIDForName(t *testing.T) {
+	t.Run("returns the id of the matching zone", func(t *testing.T) {
+		ts := fakeZonesServer(t, map[string]string{"example.com.": "zone-1"}, nil)
+		defer ts.Close()
+
+		id, err := zoneIDForName(context.Background(), fakeDNSClient(ts), "example.com.")
+		if err != nil {
+			t.Fatalf("zoneIDForName() error = %s", err)
+		}
+		if id != "zone-1" {
+			t.Fatalf("zoneIDForName() = %q, want %q", id, "zone-1")
+		}
+	})
+
+	t.Run("adds a trailing dot before querying", func(t *testing.T) {
+		ts := fakeZonesServer(t, map[string]string{"example.com.": "zone-1"}, nil)
+		defer ts.Close()
+
+		id, err := zoneIDForName(context.Background(), fakeDNSClient(ts), "example.com")
+		if err != nil {
+			t.Fatalf("zoneIDForName() error = %s", err)
+		}
+		if id != "zone-1" {
+			t.Fatalf("zoneIDForName() = %q, want %q", id, "zone-1")
+		}
+	})
+
+	t.Run("returns an error when no zone matches", func(t *testing.T) {
+		ts := fakeZonesServer(t, map[string]string{}, nil)
+		defer ts.Close()
+
+		if _, err := zoneIDForName(context.Background(), fakeDNSClient(ts), "example.com."); err == nil {
+			t.Fatal("zoneIDForName() error = nil, want error for no matching zone")
+		}
+	})
+}
+
+func TestDiscoverZoneID(t *testing.T) {
+	t.Run("picks the longest-matching zone the tenant owns", func(t *testing.T) {
+		var queried []string
+		ts := fakeZonesServer(t, map[string]string{
+			"example.com.": "zone-apex",
+		}, &queried)
+		defer ts.Close()
+
+		id, err := discoverZoneID(context.Background(), fakeDNSClient(ts), "_acme-challenge.a.b.example.com.")
+		if err != nil {
+			t.Fatalf("discoverZoneID() error = %s", err)
+		}
+		if id != "zone-apex" {
+			t.Fatalf("discoverZoneID() = %q, want %q", id, "zone-apex")
+		}
+
+		want := []string{
+			"_acme-challenge.a.b.example.com.",
+			"a.b.example.com.",
+			"b.example.com.",
+			"example.com.",
+		}
+		if len(queried) != len(want) {
+			t.Fatalf("discoverZoneID() queried %v, want %v", queried, want)
+		}
+		for i, name := range want {
+			if queried[i] != name {
+				t.Fatalf("discoverZoneID() query[%d] = %q, want %q", i, queried[i], name)
+			}
+		}
+	})
+
+	t.Run("prefers the more specific zone when both it and its parent exist", func(t *testing.T) {
+		ts := fakeZonesServer(t, map[string]string{
+			"b.example.com.": "zone-delegated",
+			"example.com.":   "zone-apex",
+		}, nil)
+		defer ts.Close()
+
+		id, err := discoverZoneID(context.Background(), fakeDNSClient(ts), "a.b.example.com.")
+		if err != nil {
+			t.Fatalf("discoverZoneID() error = %s", err)
+		}
+		if id != "zone-delegated" {
+			t.Fatalf("discoverZoneID() = %q, want %q", id, "zone-delegated")
+		}
+	})
+
+	t.Run("never tries the bare TLD as a candidate", func(t *testing.T) {
+		var queried []string
+		ts := fakeZonesServer(t, map[string]string{"com.": "zone-tld"}, &queried)
+		defer ts.Close()
+
+		if _, err := discoverZoneID(context.Background(), fakeDNSClient(ts), "foo.com."); err == nil {
+			t.Fatal("discoverZoneID() error = nil, want error since only the excluded TLD zone exists")
+		}
+
+		for _, name := range queried {
+			if name == "com." {
+				t.Fatalf("discoverZoneID() queried excluded TLD-only candidate %q", name)
+			}
+		}
+	})
+}
+
+func TestResolveZoneID(t *testing.T) {
+	t.Run("cfg.ZoneID is used as-is without querying Designate", func(t *testing.T) {
+		ts := fakeZonesServer(t, nil, nil)
+		defer ts.Close()
+
+		c := &designateSolver{}
+		cfg := &designateConfig{ZoneID: "zone-pinned"}
+
+		id, err := c.resolveZoneID(context.Background(), fakeDNSClient(ts), cfg, "_acme-challenge.example.com.")
+		if err != nil {
+			t.Fatalf("resolveZoneID() error = %s", err)
+		}
+		if id != "zone-pinned" {
+			t.Fatalf("resolveZoneID() = %q, want %q", id, "zone-pinned")
+		}
+	})
+
+	t.Run("cfg.ZoneName pins discovery to a single known zone", func(t *testing.T) {
+		ts := fakeZonesServer(t, map[string]string{"example.com.": "zone-named"}, nil)
+		defer ts.Close()
+
+		c := &designateSolver{}
+		cfg := &designateConfig{ZoneName: "example.com."}
+
+		id, err := c.resolveZoneID(context.Background(), fakeDNSClient(ts), cfg, "_acme-challenge.example.com.")
+		if err != nil {
+			t.Fatalf("resolveZoneID() error = %s", err)
+		}
+		if id != "zone-named" {
+			t.Fatalf("resolveZoneID() = %q, want %q", id, "zone-named")
+		}
+	})
+
+	t.Run("a cached discovery is reused without re-querying Designate", func(t *testing.T) {
+		var queried []string
+		ts := fakeZonesServer(t, map[string]string{"example.com.": "zone-apex"}, &queried)
+		defer ts.Close()
+
+		c := &designateSolver{}
+		cfg := &designateConfig{}
+		fqdn := "_acme-challenge.example.com."
+
+		if _, err := c.resolveZoneID(context.Background(), fakeDNSClient(ts), cfg, fqdn); err != nil {
+			t.Fatalf("resolveZoneID() error = %s", err)
+		}
+		firstQueryCount := len(queried)
+
+		id, err := c.resolveZoneID(context.Background(), fakeDNSClient(ts), cfg, fqdn)
+		if err != nil {
+			t.Fatalf("resolveZoneID() error = %s", err)
+		}
+		if id != "zone-apex" {
+			t.Fatalf("resolveZoneID() = %q, want %q", id, "zone-apex")
+		}
+		if len(queried) != firstQueryCount {
+			t.Fatalf("resolveZoneID() re-queried Designate on a cache hit: %v", queried)
+		}
+	})
+}